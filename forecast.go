@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// ForecastData mirrors the response shape of OpenWeather's One Call API,
+// trimmed down to the fields we actually render.
+type ForecastData struct {
+	Timezone int `json:"timezone_offset"`
+	Daily    []struct {
+		Dt   int `json:"dt"`
+		Temp struct {
+			Min float64 `json:"min"`
+			Max float64 `json:"max"`
+		} `json:"temp"`
+		Humidity int     `json:"humidity"`
+		WindSpd  float64 `json:"wind_speed"`
+		Weather  []struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+			Icon        string `json:"icon"`
+		} `json:"weather"`
+		Pop     float64 `json:"pop"`
+		Sunrise int     `json:"sunrise"`
+		Sunset  int     `json:"sunset"`
+	} `json:"daily"`
+}
+
+const forecastCacheTTL = time.Hour
+
+// GetForecast fetches a daily forecast for city via OpenWeather's One Call
+// API, geocoding the city name to coordinates first since One Call only
+// accepts lat/lon. Results are cached under a distinct prefix from
+// GetWeather with a longer TTL, since forecasts change less often.
+func GetForecast(ctx context.Context, city string, apiKey string) (*ForecastData, error) {
+	if strings.TrimSpace(city) == "" {
+		return nil, fmt.Errorf("название города не может быть пустым")
+	}
+
+	trackCityRequest(city)
+	cacheKey := fmt.Sprintf("forecast:%s:%d", city, time.Now().Truncate(forecastCacheTTL).Unix())
+	if cached, found := weatherCache.Get(cacheKey); found {
+		recordCacheHit()
+		logger.Info("кэш хит прогноза", "city", city)
+		return cached.(*ForecastData), nil
+	}
+	recordCacheMiss()
+
+	loc, err := geocodeCity(ctx, city, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := "https://api.openweathermap.org/data/3.0/onecall"
+	params := url.Values{}
+	params.Set("lat", fmt.Sprintf("%f", loc.Lat))
+	params.Set("lon", fmt.Sprintf("%f", loc.Lon))
+	params.Set("exclude", "minutely,hourly,alerts,current")
+	params.Set("appid", apiKey)
+	params.Set("units", "metric")
+	params.Set("lang", "ru")
+	reqURL := baseURL + "?" + params.Encode()
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка API прогноза: %d", resp.StatusCode)
+	}
+
+	var forecast ForecastData
+	if err := json.NewDecoder(resp.Body).Decode(&forecast); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+	}
+	if len(forecast.Daily) == 0 {
+		return nil, fmt.Errorf("прогноз не найден: %s", city)
+	}
+
+	weatherCache.Set(cacheKey, &forecast, forecastCacheTTL)
+	return &forecast, nil
+}
+
+// FormatForecastMessage renders a compact Markdown table: one row per day
+// with min/max temperature, the dominant condition's icon, precipitation
+// probability, sunrise/sunset, and the same playful tip used for current
+// weather.
+func FormatForecastMessage(city string, f *ForecastData) string {
+	cityName := cases.Title(language.Russian).String(city)
+	localOffset := time.Duration(f.Timezone) * time.Second
+
+	days := f.Daily
+	if len(days) > 5 {
+		days = days[:5]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📅 *Прогноз на %d %s — %s*\n\n", len(days), ruDayWord(len(days)), cityName)
+
+	for _, d := range days {
+		localDt := time.Unix(int64(d.Dt), 0).UTC().Add(localOffset)
+		day := cases.Title(language.Russian).String(localDt.Format("Mon, 02.01"))
+
+		icon := "🌡️"
+		desc := ""
+		if len(d.Weather) > 0 {
+			desc = d.Weather[0].Description
+			if i := WeatherIcon[d.Weather[0].Icon]; i != "" {
+				icon = i
+			}
+		}
+
+		maxTemp := int(round(d.Temp.Max))
+
+		fmt.Fprintf(&b,
+			"%s %s: %d…%d°C, %s, ☔ %d%%\n",
+			icon, day,
+			int(round(d.Temp.Min)), maxTemp,
+			cases.Title(language.Russian).String(desc), int(round(d.Pop*100)),
+		)
+		// Not every provider reports sunrise/sunset (NWS doesn't); a
+		// Unix-0 sunrise would otherwise print as a misleading 03:00.
+		if d.Sunrise != 0 || d.Sunset != 0 {
+			sunrise := time.Unix(int64(d.Sunrise), 0).UTC().Add(localOffset).Format("15:04")
+			sunset := time.Unix(int64(d.Sunset), 0).UTC().Add(localOffset).Format("15:04")
+			fmt.Fprintf(&b, "🌅 %s 🌇 %s", sunrise, sunset)
+		}
+		fmt.Fprintf(&b, "%s\n\n", weatherTip(desc, maxTemp, d.Humidity, d.WindSpd))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ruDayWord picks the grammatically correct declension of "день" for n, e.g.
+// "1 день", "2 дня", "5 дней" — plain "%d дня" reads as ungrammatical for
+// most counts.
+func ruDayWord(n int) string {
+	n = n % 100
+	if n >= 11 && n <= 14 {
+		return "дней"
+	}
+	switch n % 10 {
+	case 1:
+		return "день"
+	case 2, 3, 4:
+		return "дня"
+	default:
+		return "дней"
+	}
+}
+
+type coords struct {
+	Lat float64
+	Lon float64
+}
+
+// geocodeCity resolves a city name to coordinates via OpenWeather's geocoding
+// endpoint, since the One Call API only accepts lat/lon.
+func geocodeCity(ctx context.Context, city string, apiKey string) (*coords, error) {
+	params := url.Values{}
+	params.Set("q", city+",RU")
+	params.Set("limit", "1")
+	params.Set("appid", apiKey)
+	reqURL := "https://api.openweathermap.org/geo/1.0/direct?" + params.Encode()
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса геокодирования: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса геокодирования: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка геокодирования: %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON геокодирования: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("город не найден: %s", city)
+	}
+	return &coords{Lat: results[0].Lat, Lon: results[0].Lon}, nil
+}
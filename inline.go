@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// inlineDebounce holds the latest pending timer per user, so fast typing in
+// Telegram's inline search box doesn't fire an upstream request per
+// keystroke — only once input has settled for inlineDebounceDelay.
+var (
+	inlineDebounce      sync.Map // userID -> *time.Timer
+	inlineDebounceDelay = 500 * time.Millisecond
+)
+
+// handleInlineQuery debounces and answers an inline query (`@bot city`)
+// with current conditions, tomorrow's forecast, and a compact share
+// variant, so the user can pick which to post into the chat.
+func handleInlineQuery(bot *tgbotapi.BotAPI, provider WeatherProvider, query tgbotapi.InlineQuery) {
+	userID := query.From.ID
+	if existing, ok := inlineDebounce.Load(userID); ok {
+		existing.(*time.Timer).Stop()
+	}
+
+	timer := time.AfterFunc(inlineDebounceDelay, func() {
+		inlineDebounce.Delete(userID)
+		answerInlineQuery(bot, provider, query)
+	})
+	inlineDebounce.Store(userID, timer)
+}
+
+func answerInlineQuery(bot *tgbotapi.BotAPI, provider WeatherProvider, query tgbotapi.InlineQuery) {
+	city := strings.TrimSpace(query.Query)
+	if city == "" {
+		return
+	}
+
+	cacheKey := fmt.Sprintf("inline:%s:%d", normalizeCity(city), time.Now().Truncate(10*time.Minute).Unix())
+	var results []interface{}
+	if cached, found := weatherCache.Get(cacheKey); found {
+		results = cached.([]interface{})
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+		defer cancel()
+		results = buildInlineResults(ctx, provider, city)
+		if len(results) > 0 {
+			weatherCache.Set(cacheKey, results, 10*time.Minute)
+		}
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     0,
+	}
+	if _, err := bot.Request(answer); err != nil {
+		logger.Error("ошибка ответа на inline-запрос", "error", err)
+	}
+}
+
+func buildInlineResults(ctx context.Context, provider WeatherProvider, city string) []interface{} {
+	var results []interface{}
+
+	if weather, err := provider.Current(ctx, Location{City: city}); err == nil {
+		results = append(results, inlineArticle(city, "current", "🌍 Сейчас", FormatWeatherMessage(weather)))
+
+		shortMsg := fmt.Sprintf("%s %d°C", WeatherIcon[weather.Weather[0].Icon], int(round(weather.Main.Temp)))
+		results = append(results, inlineArticle(city, "share", "📤 Поделиться погодой", shortMsg))
+	}
+
+	if forecast, err := provider.Forecast(ctx, Location{City: city}); err == nil && len(forecast.Daily) > 1 {
+		tomorrow := &ForecastData{Timezone: forecast.Timezone, Daily: forecast.Daily[1:2]}
+		results = append(results, inlineArticle(city, "tomorrow", "📅 Завтра", FormatForecastMessage(city, tomorrow)))
+	}
+
+	return results
+}
+
+func inlineArticle(city, variant, title, messageText string) tgbotapi.InlineQueryResultArticle {
+	id := inlineResultID(city, variant)
+	article := tgbotapi.NewInlineQueryResultArticle(id, title, messageText)
+	article.InputMessageContent = tgbotapi.InputTextMessageContent{
+		Text:      messageText,
+		ParseMode: tgbotapi.ModeMarkdown,
+	}
+	return article
+}
+
+// inlineResultID must be unique and stable per (city, variant) within a
+// single answer, which a hash of the pair guarantees.
+func inlineResultID(city, variant string) string {
+	sum := sha1.Sum([]byte(normalizeCity(city) + ":" + variant))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/patrickmn/go-cache"
+)
+
+// GetWeatherByCoords fetches current weather for exact coordinates, e.g. from
+// a Telegram Location message. Coordinates are rounded to 2 decimals for the
+// cache key (roughly 1km precision), since nearby taps should still hit the
+// same cache entry as GetWeather does for city names.
+func GetWeatherByCoords(ctx context.Context, lat float64, lon float64, apiKey string) (*WeatherData, error) {
+	cacheKey := fmt.Sprintf("weather:coords:%.2f,%.2f:%d", lat, lon, time.Now().Truncate(10*time.Minute).Unix())
+	if cached, found := weatherCache.Get(cacheKey); found {
+		recordCacheHit()
+		logger.Info("кэш хит", "lat", lat, "lon", lon)
+		return cached.(*WeatherData), nil
+	}
+	recordCacheMiss()
+
+	baseURL := "https://api.openweathermap.org/data/2.5/weather"
+	params := url.Values{}
+	params.Set("lat", fmt.Sprintf("%f", lat))
+	params.Set("lon", fmt.Sprintf("%f", lon))
+	params.Set("appid", apiKey)
+	params.Set("units", "metric")
+	params.Set("lang", "ru")
+	reqURL := baseURL + "?" + params.Encode()
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка API: %d", resp.StatusCode)
+	}
+
+	var weather WeatherData
+	if err := json.NewDecoder(resp.Body).Decode(&weather); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+	}
+	if weather.Name == "" {
+		return nil, fmt.Errorf("не удалось определить местоположение по координатам")
+	}
+
+	weatherCache.Set(cacheKey, &weather, cache.DefaultExpiration)
+	return &weather, nil
+}
+
+// locationKeyboard is shown on /start so mobile users can share their exact
+// location instead of typing a city name.
+var locationKeyboard = tgbotapi.NewReplyKeyboard(
+	tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButtonLocation("📍 Отправить геолокацию"),
+	),
+)
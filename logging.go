@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// logger is the process-wide structured logger. JSON output makes the bot's
+// logs queryable by an operator running it behind a real deployment.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// SetDefault so the storage/scheduler packages, which can't import package
+// main, get the same JSON handler via the top-level slog.Info/Warn/Error
+// functions.
+func init() {
+	slog.SetDefault(logger)
+}
+
+// logRequest emits one structured record per user-facing weather request
+// with the fields operators actually filter/alert on.
+func logRequest(command, city string, chatID int64, cacheHit bool, start time.Time, provider WeatherProvider, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	} else {
+		recordSuccessfulFetch()
+	}
+	providerName := fmt.Sprintf("%T", provider)
+	latency := time.Since(start)
+
+	logger.Info("weather_request",
+		"command", command,
+		"city", city,
+		"chat_id", chatID,
+		"cache_hit", cacheHit,
+		"latency_ms", latency.Milliseconds(),
+		"provider", providerName,
+		"status", status,
+	)
+
+	requestsTotal.WithLabelValues(command, status).Inc()
+	apiLatencySeconds.WithLabelValues(providerName).Observe(latency.Seconds())
+	if cacheHit {
+		cacheHitsTotal.Inc()
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -16,21 +17,31 @@ import (
 	"github.com/patrickmn/go-cache"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+
+	"github.com/OlegalasZav/weather-bot/scheduler"
+	"github.com/OlegalasZav/weather-bot/storage"
 )
 
 type Config struct {
-	TelegramToken string
-	WeatherAPIKey string
+	TelegramToken   string
+	WeatherAPIKey   string
+	WeatherProvider string
+	MetricsPort     string
+	AdminChatID     int64
 }
 
 func NewConfig() *Config {
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("⚠️ .env файл не найден — используем переменные окружения")
+		logger.Warn(".env файл не найден — используем переменные окружения")
 	}
+	adminChatID, _ := strconv.ParseInt(os.Getenv("ADMIN_CHAT_ID"), 10, 64)
 	return &Config{
-		TelegramToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
-		WeatherAPIKey: os.Getenv("OPENWEATHER_API_KEY"),
+		TelegramToken:   os.Getenv("TELEGRAM_BOT_TOKEN"),
+		WeatherAPIKey:   os.Getenv("OPENWEATHER_API_KEY"),
+		WeatherProvider: os.Getenv("WEATHER_PROVIDER"),
+		MetricsPort:     os.Getenv("METRICS_PORT"),
+		AdminChatID:     adminChatID,
 	}
 }
 
@@ -77,13 +88,35 @@ var (
 	weatherCache = cache.New(10*time.Minute, 15*time.Minute)
 )
 
+// weatherCacheKey returns GetWeather's cache key for city at the 10-minute
+// bucket containing t, so callers (prefetch included) can target a specific
+// bucket instead of always reading through "now".
+func weatherCacheKey(city string, t time.Time) string {
+	return fmt.Sprintf("weather:%s:%d", city, t.Truncate(10*time.Minute).Unix())
+}
+
 func GetWeather(ctx context.Context, city string, apiKey string) (*WeatherData, error) {
-	cacheKey := fmt.Sprintf("weather:%s:%d", city, time.Now().Truncate(10*time.Minute).Unix())
+	trackCityRequest(city)
+	cacheKey := weatherCacheKey(city, time.Now())
 	if cached, found := weatherCache.Get(cacheKey); found {
-		log.Printf("📦 Кэш хит для %s", city)
+		recordCacheHit()
+		logger.Info("кэш хит", "city", city)
 		return cached.(*WeatherData), nil
 	}
+	recordCacheMiss()
+
+	weather, err := fetchWeather(ctx, city, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	weatherCache.Set(cacheKey, weather, cache.DefaultExpiration)
+	return weather, nil
+}
 
+// fetchWeather does the actual OpenWeather current-conditions call, with no
+// cache involved, so both GetWeather and the prefetch worker (which needs to
+// populate a cache bucket other than "now") can share it.
+func fetchWeather(ctx context.Context, city string, apiKey string) (*WeatherData, error) {
 	if strings.TrimSpace(city) == "" {
 		return nil, fmt.Errorf("название города не может быть пустым")
 	}
@@ -93,9 +126,9 @@ func GetWeather(ctx context.Context, city string, apiKey string) (*WeatherData,
 	params.Set("appid", apiKey)
 	params.Set("units", "metric")
 	params.Set("lang", "ru")
-	url := baseURL + "?" + params.Encode()
+	reqURL := baseURL + "?" + params.Encode()
 	client := &http.Client{Timeout: 8 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
@@ -114,10 +147,22 @@ func GetWeather(ctx context.Context, city string, apiKey string) (*WeatherData,
 	if weather.Name == "" {
 		return nil, fmt.Errorf("город не найден: %s", city)
 	}
-	weatherCache.Set(cacheKey, &weather, cache.DefaultExpiration)
 	return &weather, nil
 }
 
+// PrefetchWeather warms the cache bucket GetWeather will roll into next (not
+// the current one, which routing through GetWeather would just re-read),
+// so the first real request after the boundary lands warm.
+func PrefetchWeather(ctx context.Context, city string, apiKey string) error {
+	weather, err := fetchWeather(ctx, city, apiKey)
+	if err != nil {
+		return err
+	}
+	nextBucket := time.Now().Truncate(10 * time.Minute).Add(10 * time.Minute)
+	weatherCache.Set(weatherCacheKey(city, nextBucket), weather, cache.DefaultExpiration)
+	return nil
+}
+
 func FormatWeatherMessage(w *WeatherData) string {
 	desc := w.Weather[0].Description
 	iconCode := w.Weather[0].Icon
@@ -151,35 +196,38 @@ func FormatWeatherMessage(w *WeatherData) string {
 		windSpeed,
 	)
 
-	var tip string
+	return baseMsg + weatherTip(desc, temp, humidity, windSpeed)
+}
+
+// weatherTip picks a playful one-liner for the given conditions. It backs
+// both FormatWeatherMessage and the per-day forecast rendering.
+func weatherTip(desc string, temp int, humidity int, windSpeed float64) string {
 	switch {
 	case strings.Contains(strings.ToLower(desc), "дождь"):
-		tip = " ☔ Льёт как из ведра! Зонт бери или танцуй под ливнем, как в клипе! 💃"
+		return " ☔ Льёт как из ведра! Зонт бери или танцуй под ливнем, как в клипе! 💃"
 	case strings.Contains(strings.ToLower(desc), "снег"):
-		tip = " ❄️ Снежок идёт! Лепи снеговика или греми чайник для какао! ☕⛄"
+		return " ❄️ Снежок идёт! Лепи снеговика или греми чайник для какао! ☕⛄"
 	case strings.Contains(strings.ToLower(desc), "гроз"):
-		tip = " ⛈️ Гром гремит! Сиди дома, смотри кино, молния — не твой бро! 😬"
+		return " ⛈️ Гром гремит! Сиди дома, смотри кино, молния — не твой бро! 😬"
 	case temp > 30:
-		tip = " 🔥 Пекло! Хватай мороженое и ныряй в тень, бро! 🍦🌴"
+		return " 🔥 Пекло! Хватай мороженое и ныряй в тень, бро! 🍦🌴"
 	case temp > 25:
-		tip = " ☀️ Жарковато! Коктейль в парке или кондей на полную? Выбирай wisely! 🍹"
+		return " ☀️ Жарковато! Коктейль в парке или кондей на полную? Выбирай wisely! 🍹"
 	case temp < -10:
-		tip = " 🥶 Ледяной апокалипсис! Укутайся, как пингвин, и пей горячий чай! 🧣☕"
+		return " 🥶 Ледяной апокалипсис! Укутайся, как пингвин, и пей горячий чай! 🧣☕"
 	case temp < 0:
-		tip = " ❄️ Холодрыга! Шарф, шапка и тёплые носки — твой must-have! 🧦"
+		return " ❄️ Холодрыга! Шарф, шапка и тёплые носки — твой must-have! 🧦"
 	case humidity > 80:
-		tip = " 💧 Влажность зашкаливает! Крем от сырости или просто chill у воды? 🌊"
+		return " 💧 Влажность зашкаливает! Крем от сырости или просто chill у воды? 🌊"
 	case windSpeed > 15:
-		tip = " 🌪️ Ветрище штормовой! Держи шляпу и не улети, как Карлсон! 🚁"
+		return " 🌪️ Ветрище штормовой! Держи шляпу и не улети, как Карлсон! 🚁"
 	case windSpeed > 10:
-		tip = " 💨 Ветер крепкий! Завяжи шнурки потуже, а то унесёт к приключениям! 😎"
+		return " 💨 Ветер крепкий! Завяжи шнурки потуже, а то унесёт к приключениям! 😎"
 	case strings.Contains(strings.ToLower(desc), "ясно"):
-		tip = " 🌞 Солнце сияет! Хватай очки и гуляй, пока погода шепчет! 😎🚶‍♂️"
+		return " 🌞 Солнце сияет! Хватай очки и гуляй, пока погода шепчет! 😎🚶‍♂️"
 	default:
-		tip = " 😎 Погода — кайф! Выходи на улицу, лови вайб и наслаждайся! 🌳🎉"
+		return " 😎 Погода — кайф! Выходи на улицу, лови вайб и наслаждайся! 🌳🎉"
 	}
-
-	return baseMsg + tip
 }
 
 func round(f float64) float64 {
@@ -192,16 +240,62 @@ func round(f float64) float64 {
 func main() {
 	cfg := NewConfig()
 	if cfg.TelegramToken == "" {
-		log.Fatal("❌ TELEGRAM_BOT_TOKEN не задан. Добавь его в .env")
-	}
-	if cfg.WeatherAPIKey == "" {
-		log.Fatal("❌ OPENWEATHER_API_KEY не задан. Добавь его в .env")
+		logger.Error("TELEGRAM_BOT_TOKEN не задан, добавь его в .env")
+		os.Exit(1)
 	}
+	startMetricsServer(cfg.MetricsPort)
+	provider := NewProvider(cfg)
 	bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
-		log.Fatal("❌ Не удалось создать бота:", err)
+		logger.Error("не удалось создать бота", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("бот запущен", "username", bot.Self.UserName)
+
+	subStore, err := storage.Open(subscriptionsDBPath)
+	if err != nil {
+		logger.Error("не удалось открыть базу подписок", "error", err)
+		os.Exit(1)
+	}
+	defer subStore.Close()
+	if all, err := subStore.ListAll(); err == nil {
+		activeSubscribers.Set(float64(len(all)))
 	}
-	log.Printf("✅ Бот @%s запущен! (команды с меню)", bot.Self.UserName)
+
+	pushScheduler := scheduler.New(subStore,
+		func(ctx context.Context, city string) (string, error) {
+			weather, err := provider.Current(ctx, Location{City: city})
+			if err != nil {
+				return "", err
+			}
+			msg := FormatWeatherMessage(weather)
+			if forecast, err := provider.Forecast(ctx, Location{City: city}); err == nil {
+				short := *forecast
+				if len(short.Daily) > 2 {
+					short.Daily = short.Daily[:2]
+				}
+				msg += "\n\n" + FormatForecastMessage(city, &short)
+			} else {
+				logger.Warn("не удалось получить краткий прогноз для рассылки", "city", city, "error", err)
+			}
+			return msg, nil
+		},
+		func(chatID int64, text string) error {
+			msg := tgbotapi.NewMessage(chatID, text)
+			msg.ParseMode = tgbotapi.ModeMarkdown
+			_, err := bot.Send(msg)
+			return err
+		},
+	)
+	if err := pushScheduler.Start(); err != nil {
+		logger.Error("не удалось запустить планировщик рассылок", "error", err)
+		os.Exit(1)
+	}
+	defer pushScheduler.Stop()
+
+	prefetchCtx, cancelPrefetch := context.WithCancel(context.Background())
+	defer cancelPrefetch()
+	initPrefetch(prefetchCtx, provider)
 
 	// Настройка команд с retry
 	for attempt := 0; attempt < 3; attempt++ {
@@ -214,17 +308,22 @@ func main() {
 			tgbotapi.BotCommand{Command: "/yekaterinburg", Description: "Погода в Екатеринбурге"},
 			tgbotapi.BotCommand{Command: "/kazan", Description: "Погода в Казани"},
 			tgbotapi.BotCommand{Command: "/anadyr", Description: "Погода в Анадыре"},
+			tgbotapi.BotCommand{Command: "/forecast", Description: "Прогноз на 5 дней: /forecast <город>"},
+			tgbotapi.BotCommand{Command: "/subscribe", Description: "Подписка на рассылку: /subscribe <город> <ЧЧ:ММ>"},
+			tgbotapi.BotCommand{Command: "/unsubscribe", Description: "Отписаться: /unsubscribe <город>"},
+			tgbotapi.BotCommand{Command: "/mysubs", Description: "Мои подписки"},
+			tgbotapi.BotCommand{Command: "/stats", Description: "Статистика кэша и топ городов (admin)"},
 		)
 		resp, err := bot.Request(commands)
 		if err == nil && resp.Ok {
-			log.Printf("✅ Команды успешно установлены")
+			logger.Info("команды установлены")
 			break
 		}
-		log.Printf("⚠️ Ошибка настройки команд (попытка %d): %v", attempt+1, err)
+		logger.Warn("ошибка настройки команд", "attempt", attempt+1, "error", err)
 		if attempt < 2 {
 			time.Sleep(time.Duration(2<<attempt) * time.Second)
 		} else {
-			log.Printf("❌ Не удалось установить команды после 3 попыток")
+			logger.Error("не удалось установить команды после 3 попыток")
 		}
 	}
 
@@ -232,6 +331,37 @@ func main() {
 	updateConfig.Timeout = 60
 	updates := bot.GetUpdatesChan(updateConfig)
 	for update := range updates {
+		// Inline mode itself is toggled once via @BotFather's /setinline —
+		// there's no Bot API call for it, only the query handling below.
+		if update.InlineQuery != nil {
+			handleInlineQuery(bot, provider, *update.InlineQuery)
+			continue
+		}
+		if update.Message != nil && update.Message.Location != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+			loc := update.Message.Location
+			start := time.Now()
+			hitsBefore := atomic.LoadInt64(&cacheHits)
+			weather, err := provider.Current(ctx, Location{Lat: loc.Latitude, Lon: loc.Longitude, HasCoords: true})
+			logRequest("location", "", update.Message.Chat.ID, atomic.LoadInt64(&cacheHits) > hitsBefore, start, provider, err)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("❌ Ошибка: %v", err))
+				_, sendErr := bot.Send(msg)
+				if sendErr != nil {
+					logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", sendErr)
+				}
+				cancel()
+				continue
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, FormatWeatherMessage(weather))
+			msg.ParseMode = tgbotapi.ModeMarkdown
+			_, err = bot.Send(msg)
+			if err != nil {
+				logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", err)
+			}
+			cancel()
+			continue
+		}
 		if update.Message != nil && update.Message.Text != "" {
 			ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
 
@@ -248,27 +378,152 @@ func main() {
 						"/yekaterinburg — Погода в Екатеринбурге\n"+
 						"/kazan — Погода в Казани\n"+
 						"/anadyr — Погода в Анадыре\n"+
+						"/forecast <город> — Прогноз на 5 дней\n"+
+						"/subscribe <город> <ЧЧ:ММ> — Ежедневная рассылка\n"+
+						"/unsubscribe <город> — Отписаться от рассылки\n"+
+						"/mysubs — Мои подписки\n"+
 						"/help — Показать это снова\n"+
 						"Лови вайб и погоду! 😎🚶‍♂️")
 				msg.ParseMode = tgbotapi.ModeMarkdown
+				msg.ReplyMarkup = locationKeyboard
 				_, err := bot.Send(msg)
 				if err != nil {
-					log.Printf("❌ Ошибка отправки подсказки: %v", err)
+					logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", err)
 				}
 				cancel() // Явный вызов вместо defer
 				continue
+			} else if strings.HasPrefix(text, "/forecast") {
+				city = strings.TrimSpace(strings.TrimPrefix(text, "/forecast"))
+				if city == "" {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ Укажи город: /forecast Москва")
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				start := time.Now()
+				hitsBefore := atomic.LoadInt64(&cacheHits)
+				forecast, err := provider.Forecast(ctx, Location{City: city})
+				logRequest("forecast", city, update.Message.Chat.ID, atomic.LoadInt64(&cacheHits) > hitsBefore, start, provider, err)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("❌ Ошибка: %v", err))
+					_, sendErr := bot.Send(msg)
+					if sendErr != nil {
+						logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", sendErr)
+					}
+					cancel()
+					continue
+				}
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, FormatForecastMessage(city, forecast))
+				msg.ParseMode = tgbotapi.ModeMarkdown
+				_, err = bot.Send(msg)
+				if err != nil {
+					logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", err)
+				}
+				cancel()
+				continue
+			} else if strings.HasPrefix(text, "/subscribe") {
+				chatID := update.Message.Chat.ID
+				subCity, hhmm, err := parseSubscribeArgs(strings.TrimPrefix(text, "/subscribe"))
+				if err != nil {
+					msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %v", err))
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				weather, err := provider.Current(ctx, Location{City: subCity})
+				if err != nil {
+					msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка: %v", err))
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				tzOffset, err := resolveTZOffset(ctx, subCity)
+				if err != nil {
+					logger.Warn("не удалось определить часовой пояс, использую смещение из погоды", "city", subCity, "error", err)
+					tzOffset = weather.Timezone
+				}
+				sub := storage.Subscription{ChatID: chatID, City: subCity, LocalTime: hhmm, TZOffsetSec: tzOffset}
+				if err := subStore.Subscribe(sub); err != nil {
+					logger.Error("ошибка сохранения подписки", "chat_id", chatID, "error", err)
+					msg := tgbotapi.NewMessage(chatID, "❌ Не удалось сохранить подписку, попробуй позже")
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				activeSubscribers.Inc()
+				msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Подписал на рассылку по *%s* в %s каждый день", subCity, hhmm))
+				msg.ParseMode = tgbotapi.ModeMarkdown
+				_, _ = bot.Send(msg)
+				cancel()
+				continue
+			} else if strings.HasPrefix(text, "/unsubscribe") {
+				chatID := update.Message.Chat.ID
+				subCity := strings.TrimSpace(strings.TrimPrefix(text, "/unsubscribe"))
+				if subCity == "" {
+					msg := tgbotapi.NewMessage(chatID, "❌ Укажи город: /unsubscribe Москва")
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				existed, err := subStore.Unsubscribe(chatID, subCity)
+				if err != nil {
+					logger.Error("ошибка удаления подписки", "chat_id", chatID, "error", err)
+					msg := tgbotapi.NewMessage(chatID, "❌ Не удалось отписаться, попробуй позже")
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				if existed {
+					activeSubscribers.Dec()
+				}
+				msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Отписал от рассылки по *%s*", subCity))
+				msg.ParseMode = tgbotapi.ModeMarkdown
+				_, _ = bot.Send(msg)
+				cancel()
+				continue
+			} else if text == "/stats" {
+				chatID := update.Message.Chat.ID
+				if cfg.AdminChatID == 0 || chatID != cfg.AdminChatID {
+					msg := tgbotapi.NewMessage(chatID, "❌ Команда доступна только администратору")
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				msg := tgbotapi.NewMessage(chatID, FormatStats())
+				msg.ParseMode = tgbotapi.ModeMarkdown
+				_, _ = bot.Send(msg)
+				cancel()
+				continue
+			} else if text == "/mysubs" {
+				chatID := update.Message.Chat.ID
+				subs, err := subStore.ListByChat(chatID)
+				if err != nil {
+					logger.Error("ошибка чтения подписок", "chat_id", chatID, "error", err)
+					msg := tgbotapi.NewMessage(chatID, "❌ Не удалось прочитать подписки, попробуй позже")
+					_, _ = bot.Send(msg)
+					cancel()
+					continue
+				}
+				msg := tgbotapi.NewMessage(chatID, FormatSubscriptionList(subs))
+				msg.ParseMode = tgbotapi.ModeMarkdown
+				_, _ = bot.Send(msg)
+				cancel()
+				continue
 			} else if cityName, ok := CityMap[text]; ok {
 				city = cityName
 			} else {
 				city = update.Message.Text // Ввод вручную
 			}
 
-			weather, err := GetWeather(ctx, city, cfg.WeatherAPIKey)
+			start := time.Now()
+			hitsBefore := atomic.LoadInt64(&cacheHits)
+			weather, err := provider.Current(ctx, Location{City: city})
+			logRequest("city", city, update.Message.Chat.ID, atomic.LoadInt64(&cacheHits) > hitsBefore, start, provider, err)
 			if err != nil {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("❌ Ошибка: %v", err))
 				_, sendErr := bot.Send(msg)
 				if sendErr != nil {
-					log.Printf("❌ Ошибка отправки ошибки: %v", sendErr)
+					logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", sendErr)
 				}
 				cancel() // Явный вызов вместо defer
 				continue
@@ -278,7 +533,7 @@ func main() {
 			msg.ParseMode = tgbotapi.ModeMarkdown
 			_, err = bot.Send(msg)
 			if err != nil {
-				log.Printf("❌ Ошибка отправки погоды: %v", err)
+				logger.Error("ошибка отправки сообщения", "chat_id", update.Message.Chat.ID, "error", err)
 			}
 			cancel() // Явный вызов вместо defer
 		}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weatherbot_requests_total",
+		Help: "Total weather requests by command and outcome.",
+	}, []string{"command", "status"})
+
+	apiLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "weatherbot_api_latency_seconds",
+		Help:    "Upstream weather provider latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	cacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weatherbot_cache_hits_total",
+		Help: "Total weather cache hits.",
+	})
+
+	activeSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "weatherbot_active_subscribers",
+		Help: "Current number of subscription rows.",
+	})
+)
+
+// lastSuccessfulFetchUnixNano backs /healthz: the bot is considered healthy
+// as long as some weather provider answered recently, regardless of which
+// one is active.
+var lastSuccessfulFetchUnixNano int64
+
+func recordSuccessfulFetch() {
+	atomic.StoreInt64(&lastSuccessfulFetchUnixNano, time.Now().UnixNano())
+}
+
+// startMetricsServer serves /metrics and /healthz on METRICS_PORT in the
+// background. A blank port disables it, since not every deployment needs
+// scraping.
+func startMetricsServer(port string) {
+	if port == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler)
+	go func() {
+		if err := http.ListenAndServe(":"+port, mux); err != nil {
+			logger.Error("сервер метрик остановлен", "error", err)
+		}
+	}()
+	logger.Info("сервер метрик запущен", "port", port)
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	last := atomic.LoadInt64(&lastSuccessfulFetchUnixNano)
+	if last == 0 || time.Since(time.Unix(0, last)) > 5*time.Minute {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
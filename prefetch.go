@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const prefetchTopN = 5
+
+// cachePrefetcher is implemented by providers that cache responses keyed to
+// GetWeather's rolling 10-minute bucket. Reading through Current always
+// resolves to the *current* bucket (whose entry, if any, is already warm),
+// so prefetch needs this extra method to target the bucket users will
+// actually hit once the boundary passes.
+type cachePrefetcher interface {
+	PrefetchCurrent(ctx context.Context, city string) error
+}
+
+// initPrefetch proactively refetches the top-N most requested cities a
+// minute before each 10-minute cache boundary (the same grid GetWeather
+// truncates to) expires, so bursts of traffic right after the boundary
+// land on a warm cache instead of a thundering herd of upstream calls.
+func initPrefetch(ctx context.Context, provider WeatherProvider) {
+	go func() {
+		for {
+			next := time.Now().Truncate(10 * time.Minute).Add(10*time.Minute - time.Minute)
+			wait := time.Until(next)
+			if wait <= 0 {
+				wait += 10 * time.Minute
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				prefetchTopCities(ctx, provider)
+			}
+		}
+	}()
+}
+
+func prefetchTopCities(ctx context.Context, provider WeatherProvider) {
+	cp, ok := provider.(cachePrefetcher)
+	if !ok {
+		logger.Warn("активный провайдер не кэширует ответы — прогрев кэша пропущен")
+		return
+	}
+	for _, c := range topCities(prefetchTopN) {
+		fetchCtx, cancel := context.WithTimeout(ctx, 8*time.Second)
+		if err := cp.PrefetchCurrent(fetchCtx, c.Original); err != nil {
+			logger.Warn("не удалось прогреть кэш", "city", c.Original, "error", err)
+		}
+		cancel()
+	}
+}
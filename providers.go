@@ -0,0 +1,592 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location identifies where to fetch weather for, either by city name or by
+// exact coordinates (e.g. from a Telegram Location message).
+type Location struct {
+	City      string
+	Lat       float64
+	Lon       float64
+	HasCoords bool
+}
+
+// WeatherProvider abstracts the upstream weather API so the bot can fall
+// back to a keyless source when OPENWEATHER_API_KEY is missing or the quota
+// is exhausted.
+type WeatherProvider interface {
+	Current(ctx context.Context, loc Location) (*WeatherData, error)
+	Forecast(ctx context.Context, loc Location) (*ForecastData, error)
+}
+
+// NewProvider builds the WeatherProvider configured via WEATHER_PROVIDER. If
+// the OpenWeather key is missing, it silently falls back to a chain of
+// keyless providers so the bot keeps working without any configuration.
+func NewProvider(cfg *Config) WeatherProvider {
+	switch cfg.WeatherProvider {
+	case "nws":
+		return &NWSProvider{}
+	case "wttr":
+		return &WttrProvider{}
+	case "openweather":
+		if cfg.WeatherAPIKey != "" {
+			return &OpenWeatherProvider{APIKey: cfg.WeatherAPIKey}
+		}
+		logger.Warn("OPENWEATHER_API_KEY не задан — переключаюсь на keyless-провайдеры")
+		fallthrough
+	default:
+		providers := []WeatherProvider{&NWSProvider{}, &WttrProvider{}}
+		if cfg.WeatherAPIKey != "" {
+			providers = append([]WeatherProvider{&OpenWeatherProvider{APIKey: cfg.WeatherAPIKey}}, providers...)
+		}
+		return &ChainProvider{Providers: providers}
+	}
+}
+
+// OpenWeatherProvider is the original provider, backed by the OpenWeather
+// current weather and One Call APIs.
+type OpenWeatherProvider struct {
+	APIKey string
+}
+
+func (p *OpenWeatherProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	if loc.HasCoords {
+		return GetWeatherByCoords(ctx, loc.Lat, loc.Lon, p.APIKey)
+	}
+	return GetWeather(ctx, loc.City, p.APIKey)
+}
+
+func (p *OpenWeatherProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	return GetForecast(ctx, loc.City, p.APIKey)
+}
+
+// PrefetchCurrent warms GetWeather's next cache bucket for city. It
+// implements cachePrefetcher so the prefetch worker can target the bucket
+// users will actually hit after the boundary, instead of reading through
+// Current and always landing on "now".
+func (p *OpenWeatherProvider) PrefetchCurrent(ctx context.Context, city string) error {
+	return PrefetchWeather(ctx, city, p.APIKey)
+}
+
+// ChainProvider tries each provider in order, returning the first successful
+// result. This is how the bot stays up through an OpenWeather outage or
+// quota exhaustion.
+type ChainProvider struct {
+	Providers []WeatherProvider
+}
+
+func (p *ChainProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	var lastErr error
+	for _, provider := range p.Providers {
+		w, err := provider.Current(ctx, loc)
+		if err == nil {
+			return w, nil
+		}
+		logger.Warn("провайдер не справился", "provider", fmt.Sprintf("%T", provider), "error", err)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("все провайдеры погоды недоступны: %w", lastErr)
+}
+
+func (p *ChainProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	var lastErr error
+	for _, provider := range p.Providers {
+		f, err := provider.Forecast(ctx, loc)
+		if err == nil {
+			return f, nil
+		}
+		logger.Warn("провайдер не справился с прогнозом", "provider", fmt.Sprintf("%T", provider), "error", err)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("все провайдеры прогноза недоступны: %w", lastErr)
+}
+
+// PrefetchCurrent forwards to the first chained provider that supports
+// cache prefetching (currently only OpenWeatherProvider caches at all).
+func (p *ChainProvider) PrefetchCurrent(ctx context.Context, city string) error {
+	for _, provider := range p.Providers {
+		if cp, ok := provider.(cachePrefetcher); ok {
+			return cp.PrefetchCurrent(ctx, city)
+		}
+	}
+	return fmt.Errorf("ни один провайдер в цепочке не поддерживает прогрев кэша")
+}
+
+// geocodeOpenMeteo resolves a city name to coordinates using Open-Meteo's
+// keyless geocoding API, for providers that need lat/lon but have no
+// OpenWeather key to geocode with.
+func geocodeOpenMeteo(ctx context.Context, city string) (lat, lon float64, err error) {
+	params := url.Values{}
+	params.Set("name", city)
+	params.Set("count", "1")
+	reqURL := "https://geocoding-api.open-meteo.com/v1/search?" + params.Encode()
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка создания запроса геокодирования: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка HTTP-запроса геокодирования: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("ошибка геокодирования: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, 0, fmt.Errorf("ошибка парсинга JSON геокодирования: %w", err)
+	}
+	if len(result.Results) == 0 {
+		return 0, 0, fmt.Errorf("город не найден: %s", city)
+	}
+	return result.Results[0].Latitude, result.Results[0].Longitude, nil
+}
+
+func resolveCoords(ctx context.Context, loc Location) (lat, lon float64, err error) {
+	if loc.HasCoords {
+		return loc.Lat, loc.Lon, nil
+	}
+	if strings.TrimSpace(loc.City) == "" {
+		return 0, 0, fmt.Errorf("не указан ни город, ни координаты")
+	}
+	return geocodeOpenMeteo(ctx, loc.City)
+}
+
+// NWSProvider uses api.weather.gov, the US National Weather Service's free,
+// keyless API. It is US-only: coordinates outside the US return a 404 from
+// the points lookup.
+type NWSProvider struct{}
+
+func (p *NWSProvider) gridEndpoint(ctx context.Context, lat, lon float64) (forecastURL, observationStationsURL string, err error) {
+	reqURL := fmt.Sprintf("https://api.weather.gov/points/%.4f,%.4f", lat, lon)
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка создания запроса NWS: %w", err)
+	}
+	req.Header.Set("User-Agent", "weather-bot (https://github.com/OlegalasZav/weather-bot)")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка HTTP-запроса NWS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("NWS доступен только в США (код %d)", resp.StatusCode)
+	}
+
+	var points struct {
+		Properties struct {
+			Forecast            string `json:"forecast"`
+			ObservationStations string `json:"observationStations"`
+			RelativeLocation    struct {
+				Properties struct {
+					City string `json:"city"`
+				} `json:"properties"`
+			} `json:"relativeLocation"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&points); err != nil {
+		return "", "", fmt.Errorf("ошибка парсинга JSON NWS: %w", err)
+	}
+	return points.Properties.Forecast, points.Properties.ObservationStations, nil
+}
+
+func (p *NWSProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	lat, lon, err := resolveCoords(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	_, stationsURL, err := p.gridEndpoint(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", stationsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса станций NWS: %w", err)
+	}
+	req.Header.Set("User-Agent", "weather-bot (https://github.com/OlegalasZav/weather-bot)")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса станций NWS: %w", err)
+	}
+	defer resp.Body.Close()
+	var stations struct {
+		Features []struct {
+			Properties struct {
+				StationIdentifier string `json:"stationIdentifier"`
+				Name              string `json:"name"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stations); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON станций NWS: %w", err)
+	}
+	if len(stations.Features) == 0 {
+		return nil, fmt.Errorf("не найдено станций NWS рядом с координатами")
+	}
+	nearest := stations.Features[0]
+
+	obsURL := fmt.Sprintf("https://api.weather.gov/stations/%s/observations/latest", nearest.Properties.StationIdentifier)
+	req, err = http.NewRequestWithContext(ctx, "GET", obsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса наблюдений NWS: %w", err)
+	}
+	req.Header.Set("User-Agent", "weather-bot (https://github.com/OlegalasZav/weather-bot)")
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса наблюдений NWS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка наблюдений NWS: %d", resp.StatusCode)
+	}
+
+	var obs struct {
+		Properties struct {
+			Temperature      struct{ Value *float64 }
+			RelativeHumidity struct{ Value *float64 }
+			WindSpeed        struct{ Value *float64 }
+			TextDescription  string `json:"textDescription"`
+			Timestamp        string `json:"timestamp"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&obs); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON наблюдений NWS: %w", err)
+	}
+
+	w := &WeatherData{Name: nearest.Properties.Name, Dt: int(time.Now().Unix())}
+	if obs.Properties.Temperature.Value != nil {
+		w.Main.Temp = *obs.Properties.Temperature.Value
+		w.Main.FeelsLike = *obs.Properties.Temperature.Value
+	}
+	if obs.Properties.RelativeHumidity.Value != nil {
+		w.Main.Humidity = int(*obs.Properties.RelativeHumidity.Value)
+	}
+	if obs.Properties.WindSpeed.Value != nil {
+		w.Wind.Speed = *obs.Properties.WindSpeed.Value / 3.6 // km/h -> m/s
+	}
+	w.Weather = append(w.Weather, struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	}{Description: obs.Properties.TextDescription})
+	return w, nil
+}
+
+func (p *NWSProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	lat, lon, err := resolveCoords(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	forecastURL, _, err := p.gridEndpoint(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", forecastURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса прогноза NWS: %w", err)
+	}
+	req.Header.Set("User-Agent", "weather-bot (https://github.com/OlegalasZav/weather-bot)")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса прогноза NWS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка прогноза NWS: %d", resp.StatusCode)
+	}
+
+	var grid struct {
+		Properties struct {
+			Periods []struct {
+				StartTime                  string `json:"startTime"`
+				IsDaytime                  bool   `json:"isDaytime"`
+				Temperature                int    `json:"temperature"`
+				ShortForecast              string `json:"shortForecast"`
+				ProbabilityOfPrecipitation struct {
+					Value *int `json:"value"`
+				} `json:"probabilityOfPrecipitation"`
+				RelativeHumidity struct {
+					Value *int `json:"value"`
+				} `json:"relativeHumidity"`
+			} `json:"periods"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&grid); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON прогноза NWS: %w", err)
+	}
+
+	// NWS returns alternating day/night periods, but the first one can be a
+	// leading "Tonight" with isDaytime=false — fold by that flag instead of
+	// assuming periods[0] is always a day, or a night-first slice mislabels
+	// min/max and desyncs every pair after it.
+	periods := grid.Properties.Periods
+	var forecast ForecastData
+	for i := 0; i < len(periods) && len(forecast.Daily) < 5; {
+		day := periods[i]
+		if !day.IsDaytime {
+			i++
+			continue
+		}
+		startTime, err := time.Parse(time.RFC3339, day.StartTime)
+		if err != nil {
+			i++
+			continue
+		}
+
+		maxTemp, minTemp := float64(day.Temperature), float64(day.Temperature)
+		humidity := 0
+		if day.RelativeHumidity.Value != nil {
+			humidity = *day.RelativeHumidity.Value
+		}
+		pop := 0.0
+		if day.ProbabilityOfPrecipitation.Value != nil {
+			pop = float64(*day.ProbabilityOfPrecipitation.Value) / 100
+		}
+
+		if i+1 < len(periods) && !periods[i+1].IsDaytime {
+			night := periods[i+1]
+			if float64(night.Temperature) < minTemp {
+				minTemp = float64(night.Temperature)
+			}
+			if night.RelativeHumidity.Value != nil && *night.RelativeHumidity.Value > humidity {
+				humidity = *night.RelativeHumidity.Value
+			}
+			if night.ProbabilityOfPrecipitation.Value != nil {
+				if nightPop := float64(*night.ProbabilityOfPrecipitation.Value) / 100; nightPop > pop {
+					pop = nightPop
+				}
+			}
+			i += 2
+		} else {
+			i++
+		}
+
+		entry := struct {
+			Dt   int `json:"dt"`
+			Temp struct {
+				Min float64 `json:"min"`
+				Max float64 `json:"max"`
+			} `json:"temp"`
+			Humidity int     `json:"humidity"`
+			WindSpd  float64 `json:"wind_speed"`
+			Weather  []struct {
+				Main        string `json:"main"`
+				Description string `json:"description"`
+				Icon        string `json:"icon"`
+			} `json:"weather"`
+			Pop     float64 `json:"pop"`
+			Sunrise int     `json:"sunrise"`
+			Sunset  int     `json:"sunset"`
+		}{Dt: int(startTime.Unix())}
+		entry.Temp.Max = maxTemp
+		entry.Temp.Min = minTemp
+		entry.Humidity = humidity
+		entry.Pop = pop
+		entry.Weather = append(entry.Weather, struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+			Icon        string `json:"icon"`
+		}{Description: day.ShortForecast})
+		forecast.Daily = append(forecast.Daily, entry)
+	}
+	if len(forecast.Daily) == 0 {
+		return nil, fmt.Errorf("прогноз NWS пуст")
+	}
+	// NWS has no sunrise/sunset field; Sunrise/Sunset stay zero and
+	// FormatForecastMessage omits that line when unset.
+	return &forecast, nil
+}
+
+// WttrProvider uses wttr.in's JSON output (?format=j1), a free, keyless
+// weather source. It's the last resort in the default provider chain.
+type WttrProvider struct{}
+
+func (p *WttrProvider) fetch(ctx context.Context, loc Location) (*wttrResponse, error) {
+	query := loc.City
+	if loc.HasCoords {
+		query = fmt.Sprintf("%f,%f", loc.Lat, loc.Lon)
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("не указан ни город, ни координаты")
+	}
+
+	reqURL := fmt.Sprintf("https://wttr.in/%s?format=j1", url.PathEscape(query))
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса wttr.in: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP-запроса wttr.in: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка wttr.in: %d", resp.StatusCode)
+	}
+
+	var parsed wttrResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JSON wttr.in: %w", err)
+	}
+	return &parsed, nil
+}
+
+type wttrResponse struct {
+	CurrentCondition []struct {
+		TempC         string `json:"temp_C"`
+		FeelsLikeC    string `json:"FeelsLikeC"`
+		Humidity      string `json:"humidity"`
+		WindspeedKmph string `json:"windspeedKmph"`
+		WeatherDesc   []struct {
+			Value string `json:"value"`
+		} `json:"weatherDesc"`
+	} `json:"current_condition"`
+	NearestArea []struct {
+		AreaName []struct {
+			Value string `json:"value"`
+		} `json:"areaName"`
+	} `json:"nearest_area"`
+	Weather []struct {
+		Date      string `json:"date"`
+		MaxtempC  string `json:"maxtempC"`
+		MintempC  string `json:"mintempC"`
+		Astronomy []struct {
+			Sunrise string `json:"sunrise"`
+			Sunset  string `json:"sunset"`
+		} `json:"astronomy"`
+		Hourly []struct {
+			ChanceOfRain string `json:"chanceofrain"`
+			WeatherDesc  []struct {
+				Value string `json:"value"`
+			} `json:"weatherDesc"`
+		} `json:"hourly"`
+	} `json:"weather"`
+}
+
+func (p *WttrProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	data, err := p.fetch(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.CurrentCondition) == 0 {
+		return nil, fmt.Errorf("wttr.in не вернул текущие условия")
+	}
+	cur := data.CurrentCondition[0]
+
+	name := loc.City
+	if len(data.NearestArea) > 0 && len(data.NearestArea[0].AreaName) > 0 {
+		name = data.NearestArea[0].AreaName[0].Value
+	}
+
+	w := &WeatherData{Name: name, Dt: int(time.Now().Unix())}
+	w.Main.Temp = parseFloat(cur.TempC)
+	w.Main.FeelsLike = parseFloat(cur.FeelsLikeC)
+	w.Main.Humidity = int(parseFloat(cur.Humidity))
+	w.Wind.Speed = parseFloat(cur.WindspeedKmph) / 3.6 // km/h -> m/s
+	desc := ""
+	if len(cur.WeatherDesc) > 0 {
+		desc = cur.WeatherDesc[0].Value
+	}
+	w.Weather = append(w.Weather, struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	}{Description: desc})
+	return w, nil
+}
+
+func (p *WttrProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	data, err := p.fetch(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Weather) == 0 {
+		return nil, fmt.Errorf("wttr.in не вернул прогноз")
+	}
+
+	var forecast ForecastData
+	for _, day := range data.Weather {
+		startTime, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			continue
+		}
+		entry := struct {
+			Dt   int `json:"dt"`
+			Temp struct {
+				Min float64 `json:"min"`
+				Max float64 `json:"max"`
+			} `json:"temp"`
+			Humidity int     `json:"humidity"`
+			WindSpd  float64 `json:"wind_speed"`
+			Weather  []struct {
+				Main        string `json:"main"`
+				Description string `json:"description"`
+				Icon        string `json:"icon"`
+			} `json:"weather"`
+			Pop     float64 `json:"pop"`
+			Sunrise int     `json:"sunrise"`
+			Sunset  int     `json:"sunset"`
+		}{Dt: int(startTime.Unix())}
+		entry.Temp.Max = parseFloat(day.MaxtempC)
+		entry.Temp.Min = parseFloat(day.MintempC)
+		desc := ""
+		if len(day.Hourly) > 0 && len(day.Hourly[0].WeatherDesc) > 0 {
+			desc = day.Hourly[0].WeatherDesc[0].Value
+			entry.Pop = parseFloat(day.Hourly[0].ChanceOfRain) / 100
+		}
+		entry.Weather = append(entry.Weather, struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+			Icon        string `json:"icon"`
+		}{Description: desc})
+		if len(day.Astronomy) > 0 {
+			entry.Sunrise = parseWttrTime(day.Date, day.Astronomy[0].Sunrise)
+			entry.Sunset = parseWttrTime(day.Date, day.Astronomy[0].Sunset)
+		}
+		forecast.Daily = append(forecast.Daily, entry)
+	}
+	if len(forecast.Daily) == 0 {
+		return nil, fmt.Errorf("прогноз wttr.in пуст")
+	}
+	return &forecast, nil
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// parseWttrTime parses wttr.in's "06:00 AM" astronomy times against a given
+// date, returning a Unix timestamp (or 0 if it can't be parsed).
+func parseWttrTime(date, clock string) int {
+	t, err := time.Parse("2006-01-02 03:04 PM", date+" "+clock)
+	if err != nil {
+		return 0
+	}
+	return int(t.Unix())
+}
@@ -0,0 +1,91 @@
+// Package scheduler fires the daily forecast push to subscribers at their
+// requested local time, batching fetches so many subscribers to the same
+// city only cost one upstream call.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/OlegalasZav/weather-bot/storage"
+)
+
+// Fetcher returns the formatted push message for a city. It's injected
+// rather than imported so this package doesn't depend on package main's
+// provider/formatting types.
+type Fetcher func(ctx context.Context, city string) (string, error)
+
+// Sender delivers a formatted message to a chat.
+type Sender func(chatID int64, text string) error
+
+type Scheduler struct {
+	cron  *cron.Cron
+	store *storage.Store
+	fetch Fetcher
+	send  Sender
+}
+
+func New(store *storage.Store, fetch Fetcher, send Sender) *Scheduler {
+	return &Scheduler{
+		cron:  cron.New(),
+		store: store,
+		fetch: fetch,
+		send:  send,
+	}
+}
+
+// Start registers the once-a-minute tick and begins running it in the
+// background. Call Stop to shut it down.
+func (s *Scheduler) Start() error {
+	_, err := s.cron.AddFunc("* * * * *", s.tick)
+	if err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+func (s *Scheduler) Stop() {
+	s.cron.Stop()
+}
+
+// tick finds every subscriber whose local time matches the current minute,
+// groups them by city so each city is only fetched once, and pushes the
+// formatted message to each matching chat.
+func (s *Scheduler) tick() {
+	subs, err := s.store.ListAll()
+	if err != nil {
+		slog.Warn("не удалось прочитать подписки", "error", err)
+		return
+	}
+
+	due := make(map[string][]storage.Subscription)
+	for _, sub := range subs {
+		localNow := time.Now().UTC().Add(time.Duration(sub.TZOffsetSec) * time.Second).Format("15:04")
+		if localNow == sub.LocalTime {
+			due[sub.City] = append(due[sub.City], sub)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for city, subscribers := range due {
+		text, err := s.fetch(ctx, city)
+		if err != nil {
+			slog.Warn("не удалось получить рассылку", "city", city, "error", err)
+			continue
+		}
+		for _, sub := range subscribers {
+			if err := s.send(sub.ChatID, text); err != nil {
+				slog.Warn("не удалось отправить рассылку", "chat_id", sub.ChatID, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// cityStat tracks how often a (normalized) city has been requested, keeping
+// the first-seen original casing so prefetch can reuse it as the cache key.
+type cityStat struct {
+	Original string
+	Count    int64
+}
+
+var (
+	requestCounts sync.Map // normalized city -> *cityStat
+	cacheHits     int64
+	cacheMisses   int64
+)
+
+func normalizeCity(city string) string {
+	return strings.ToLower(strings.TrimSpace(city))
+}
+
+// trackCityRequest records a request for city so the prefetch worker and
+// /stats know which cities are hot.
+func trackCityRequest(city string) {
+	key := normalizeCity(city)
+	if key == "" {
+		return
+	}
+	v, _ := requestCounts.LoadOrStore(key, &cityStat{Original: city})
+	atomic.AddInt64(&v.(*cityStat).Count, 1)
+}
+
+func recordCacheHit()  { atomic.AddInt64(&cacheHits, 1) }
+func recordCacheMiss() { atomic.AddInt64(&cacheMisses, 1) }
+
+// topCities returns up to n cities by request count, highest first.
+func topCities(n int) []cityStat {
+	var all []cityStat
+	requestCounts.Range(func(_, v interface{}) bool {
+		stat := v.(*cityStat)
+		all = append(all, cityStat{Original: stat.Original, Count: atomic.LoadInt64(&stat.Count)})
+		return true
+	})
+	sort.Slice(all, func(i, j int) bool { return all[i].Count > all[j].Count })
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// FormatStats renders the /stats admin output: cache hit ratio and the top
+// requested cities, for observability into prefetch effectiveness.
+func FormatStats() string {
+	hits := atomic.LoadInt64(&cacheHits)
+	misses := atomic.LoadInt64(&cacheMisses)
+	total := hits + misses
+	var ratio float64
+	if total > 0 {
+		ratio = float64(hits) / float64(total) * 100
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 *Статистика*\nКэш: %d/%d (%.1f%%)\n\n*Топ городов:*\n", hits, total, ratio)
+	top := topCities(5)
+	if len(top) == 0 {
+		b.WriteString("пока пусто\n")
+	}
+	for i, c := range top {
+		fmt.Fprintf(&b, "%d. %s — %d\n", i+1, c.Original, c.Count)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,113 @@
+// Package storage persists user subscriptions for the scheduled daily
+// forecast push, backed by a BoltDB file so the bot doesn't need a separate
+// database server.
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const subscriptionsBucket = "subscriptions"
+
+// Subscription is one user's standing request for a daily push.
+type Subscription struct {
+	ChatID      int64  `json:"chat_id"`
+	City        string `json:"city"`
+	LocalTime   string `json:"local_time"` // "HH:MM", interpreted in TZOffsetSec
+	TZOffsetSec int    `json:"tz_offset_sec"`
+}
+
+func (s Subscription) key() []byte {
+	return []byte(fmt.Sprintf("%d:%s", s.ChatID, s.City))
+}
+
+// Store wraps a BoltDB file holding all subscriptions.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (creating if needed) the BoltDB file at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу подписок: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(subscriptionsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("не удалось создать бакет подписок: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Subscribe upserts a subscription, keyed by chat ID + city so a user can
+// subscribe to several cities but not duplicate the same one.
+func (s *Store) Subscribe(sub Subscription) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(subscriptionsBucket))
+		data, err := json.Marshal(sub)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации подписки: %w", err)
+		}
+		return b.Put(sub.key(), data)
+	})
+}
+
+// Unsubscribe removes the subscription for chatID + city, if any, reporting
+// whether it existed so callers can keep derived state (e.g. a subscriber
+// count) accurate.
+func (s *Store) Unsubscribe(chatID int64, city string) (existed bool, err error) {
+	sub := Subscription{ChatID: chatID, City: city}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(subscriptionsBucket))
+		existed = b.Get(sub.key()) != nil
+		return b.Delete(sub.key())
+	})
+	return existed, err
+}
+
+// ListByChat returns all subscriptions for a given chat, e.g. for /mysubs.
+func (s *Store) ListByChat(chatID int64) ([]Subscription, error) {
+	all, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	var filtered []Subscription
+	for _, sub := range all {
+		if sub.ChatID == chatID {
+			filtered = append(filtered, sub)
+		}
+	}
+	return filtered, nil
+}
+
+// ListAll returns every subscription, for the scheduler to batch by city.
+func (s *Store) ListAll() ([]Subscription, error) {
+	var subs []Subscription
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(subscriptionsBucket))
+		return b.ForEach(func(_, v []byte) error {
+			var sub Subscription
+			if err := json.Unmarshal(v, &sub); err != nil {
+				return fmt.Errorf("ошибка десериализации подписки: %w", err)
+			}
+			subs = append(subs, sub)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/OlegalasZav/weather-bot/storage"
+)
+
+const subscriptionsDBPath = "subscriptions.db"
+
+var hhmmPattern = regexp.MustCompile(`^([01]\d|2[0-3]):([0-5]\d)$`)
+
+// parseSubscribeArgs splits "/subscribe <city> <HH:MM>" into its parts.
+func parseSubscribeArgs(args string) (city string, hhmm string, err error) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return "", "", fmt.Errorf("используй: /subscribe <город> <ЧЧ:ММ>")
+	}
+	hhmm = fields[len(fields)-1]
+	if !hhmmPattern.MatchString(hhmm) {
+		return "", "", fmt.Errorf("неверное время %q, используй формат ЧЧ:ММ", hhmm)
+	}
+	city = strings.Join(fields[:len(fields)-1], " ")
+	return city, hhmm, nil
+}
+
+// resolveTZOffset looks up city's current UTC offset via Open-Meteo's
+// geocoding API, independently of whichever WeatherProvider is active: only
+// OpenWeatherProvider's current-weather response carries a timezone offset,
+// so subscriptions created while NWS or wttr.in is active would otherwise
+// always fire at UTC.
+func resolveTZOffset(ctx context.Context, city string) (int, error) {
+	params := url.Values{}
+	params.Set("name", city)
+	params.Set("count", "1")
+	reqURL := "https://geocoding-api.open-meteo.com/v1/search?" + params.Encode()
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания запроса часового пояса: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка HTTP-запроса часового пояса: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("ошибка геокодирования часового пояса: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []struct {
+			Timezone string `json:"timezone"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("ошибка парсинга JSON часового пояса: %w", err)
+	}
+	if len(result.Results) == 0 || result.Results[0].Timezone == "" {
+		return 0, fmt.Errorf("не удалось определить часовой пояс: %s", city)
+	}
+
+	loc, err := time.LoadLocation(result.Results[0].Timezone)
+	if err != nil {
+		return 0, fmt.Errorf("неизвестный часовой пояс %q: %w", result.Results[0].Timezone, err)
+	}
+	_, offset := time.Now().In(loc).Zone()
+	return offset, nil
+}
+
+// FormatSubscriptionList renders a user's subscriptions for /mysubs.
+func FormatSubscriptionList(subs []storage.Subscription) string {
+	if len(subs) == 0 {
+		return "🔕 У тебя пока нет подписок. Оформи через /subscribe <город> <ЧЧ:ММ>"
+	}
+	var b strings.Builder
+	b.WriteString("🔔 *Твои подписки:*\n")
+	for _, sub := range subs {
+		fmt.Fprintf(&b, "— %s в %s\n", cases.Title(language.Russian).String(sub.City), sub.LocalTime)
+	}
+	return b.String()
+}